@@ -0,0 +1,59 @@
+// Package admission adapts CreatePatchFromBytes to the response shape a
+// Kubernetes mutating admission webhook needs. It is kept separate from the
+// root jsonpatch package so that the k8s.io/api dependency it pulls in
+// never reaches callers who only need the core diff/patch functionality.
+package admission
+
+import (
+	"encoding/json"
+
+	admissionv1 "k8s.io/api/admission/v1"
+
+	"github.com/ftw-soft/jsonpatch"
+)
+
+// PatchResponseFromRaw diffs original against current (typically
+// AdmissionRequest.Object.Raw and a mutated copy of it) and returns the
+// JSONPatch response fields for an AdmissionResponse: the marshaled
+// operation array and admissionv1.PatchTypeJSONPatch.
+func PatchResponseFromRaw(original, current []byte) ([]byte, admissionv1.PatchType, error) {
+	ops, err := jsonpatch.CreatePatchFromBytes(original, current)
+	if err != nil {
+		return nil, "", err
+	}
+
+	patch, err := json.Marshal(ops)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return patch, admissionv1.PatchTypeJSONPatch, nil
+}
+
+// MutateAndPatch deep-copies obj via a JSON round-trip, runs mutate against
+// the copy, and diffs the original against the mutated copy with
+// PatchResponseFromRaw. It matches the ergonomics of controller-runtime's
+// PatchResponseFromRaw, so it can be dropped in as a replacement in a
+// mutating webhook handler.
+func MutateAndPatch[T any](obj *T, mutate func(*T) error) ([]byte, admissionv1.PatchType, error) {
+	original, err := json.Marshal(obj)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var mutated T
+	if err := json.Unmarshal(original, &mutated); err != nil {
+		return nil, "", err
+	}
+
+	if err := mutate(&mutated); err != nil {
+		return nil, "", err
+	}
+
+	current, err := json.Marshal(&mutated)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return PatchResponseFromRaw(original, current)
+}
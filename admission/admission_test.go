@@ -0,0 +1,57 @@
+package admission
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+type pod struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+func TestPatchResponseFromRaw(t *testing.T) {
+	original := `{"name":"web","labels":{"app":"web"}}`
+	current := `{"name":"web","labels":{"app":"web","injected":"true"}}`
+
+	patch, patchType, err := PatchResponseFromRaw([]byte(original), []byte(current))
+	require.NoError(t, err)
+	require.Equal(t, admissionv1.PatchTypeJSONPatch, patchType)
+	require.JSONEq(t, `[{"op":"add","path":"/labels/injected","value":"true"}]`, string(patch))
+}
+
+func TestPatchResponseFromRawInvalidJSON(t *testing.T) {
+	_, _, err := PatchResponseFromRaw([]byte("not json"), []byte("{}"))
+	require.Error(t, err)
+}
+
+func TestMutateAndPatch(t *testing.T) {
+	obj := &pod{Name: "web"}
+
+	patch, patchType, err := MutateAndPatch(obj, func(p *pod) error {
+		if p.Labels == nil {
+			p.Labels = map[string]string{}
+		}
+		p.Labels["injected"] = "true"
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, admissionv1.PatchTypeJSONPatch, patchType)
+	require.JSONEq(t, `[{"op":"add","path":"/labels","value":{"injected":"true"}}]`, string(patch))
+
+	// obj itself must be untouched: MutateAndPatch only mutates its copy.
+	require.Nil(t, obj.Labels)
+}
+
+func TestMutateAndPatchPropagatesMutatorError(t *testing.T) {
+	obj := &pod{Name: "web"}
+	wantErr := errors.New("boom")
+
+	_, _, err := MutateAndPatch(obj, func(p *pod) error {
+		return wantErr
+	})
+	require.ErrorIs(t, err, wantErr)
+}
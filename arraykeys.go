@@ -0,0 +1,119 @@
+package jsonpatch
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// arrayKeyFields returns the identity fields configured for the array at
+// path p, if any pattern in opts.ArrayKeys matches.
+func arrayKeyFields(opts Options, p string) ([]string, bool) {
+	for pattern, fields := range opts.ArrayKeys {
+		if matchesArrayKeyPattern(pattern, p) {
+			return fields, true
+		}
+	}
+	return nil, false
+}
+
+// matchesArrayKeyPattern reports whether pattern matches path, treating "*"
+// as a wildcard that matches exactly one path segment.
+func matchesArrayKeyPattern(pattern, path string) bool {
+	pSegs := strings.Split(strings.Trim(pattern, "/"), "/")
+	aSegs := strings.Split(strings.Trim(path, "/"), "/")
+	if len(pSegs) != len(aSegs) {
+		return false
+	}
+	for i, seg := range pSegs {
+		if seg == "*" {
+			continue
+		}
+		if seg != aSegs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// arrayIdentity builds a composite key from item's identity fields. ok is
+// false if item isn't an object or is missing any of the fields.
+func arrayIdentity(item any, fields []string) (key string, ok bool) {
+	obj, ok := item.(map[string]any)
+	if !ok {
+		return "", false
+	}
+	var b strings.Builder
+	for _, f := range fields {
+		v, ok := obj[f]
+		if !ok {
+			return "", false
+		}
+		fmt.Fprintf(&b, "%v\x00", v)
+	}
+	return b.String(), true
+}
+
+// diffKeyedArray diffs an array of objects by matching elements on identity
+// fields rather than position, mirroring Kubernetes' strategic-merge-patch
+// patchMergeKey. It reports ok=false when any element is missing an identity
+// field, in which case the caller should fall back to the default
+// edit-distance diff.
+func diffKeyedArray(at, bt []any, fields []string, p string, patch []Operation, opts Options) (result []Operation, ok bool, err error) {
+	aIndex := make(map[string]int, len(at))
+	for i, item := range at {
+		key, found := arrayIdentity(item, fields)
+		if !found {
+			return nil, false, nil
+		}
+		aIndex[key] = i
+	}
+
+	bIndex := make(map[string]int, len(bt))
+	for i, item := range bt {
+		key, found := arrayIdentity(item, fields)
+		if !found {
+			return nil, false, nil
+		}
+		bIndex[key] = i
+	}
+
+	// Removes must be emitted before adds, each against a's original indices
+	// in descending order, so that by the time the adds run the array has
+	// already shrunk to exactly the kept elements (in their original
+	// relative order) and ascending final indices land correctly.
+	var removedIdx []int
+	for key, ai := range aIndex {
+		if _, found := bIndex[key]; !found {
+			removedIdx = append(removedIdx, ai)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(removedIdx)))
+	for _, ai := range removedIdx {
+		patch = withTest(patch, "remove", makePathInt(p, ai), nil, at[ai], opts)
+	}
+
+	var addedIdx []int
+	for key, bi := range bIndex {
+		if _, found := aIndex[key]; !found {
+			addedIdx = append(addedIdx, bi)
+		}
+	}
+	sort.Ints(addedIdx)
+	for _, bi := range addedIdx {
+		patch = append(patch, NewOperation("add", makePathInt(p, bi), bt[bi]))
+	}
+
+	for key, ai := range aIndex {
+		bi, found := bIndex[key]
+		if !found {
+			continue
+		}
+		patch, err = handleValues(at[ai], bt[bi], makePathInt(p, bi), patch, opts)
+		if err != nil {
+			return nil, true, err
+		}
+	}
+
+	return patch, true, nil
+}
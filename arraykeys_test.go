@@ -0,0 +1,136 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"testing"
+
+	jp "github.com/evanphx/json-patch"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreatePatchWithOptionsArrayKeys(t *testing.T) {
+	opts := Options{
+		ArrayKeys: map[string][]string{
+			"/spec/tolerations": {"key"},
+		},
+	}
+
+	// oldArray/newArray (shared with TestCreatePatch) only differ by two
+	// dropped tolerations and a reordering of the remaining two; key-based
+	// matching should see past the reorder and emit just the two removes.
+	patch, err := CreatePatchWithOptions(jsonOf(t, oldArray), jsonOf(t, newArray), opts)
+	require.NoError(t, err)
+	require.Len(t, patch, 2, "only the two unmatched tolerations should be removed")
+
+	for _, op := range patch {
+		require.Equal(t, "remove", op.Operation)
+	}
+
+	// A case where the matched pairs keep their relative order round-trips
+	// cleanly, since the algorithm never emits a reordering operation.
+	a := `{"spec":{"tolerations":[{"key":"k1","value":"v1"},{"key":"k2","value":"v2"},{"key":"k3","value":"v3"}]}}`
+	b := `{"spec":{"tolerations":[{"key":"k1","value":"v1"},{"key":"k2","value":"v2-updated"}]}}`
+
+	patch2, err := CreatePatchWithOptions(jsonOf(t, a), jsonOf(t, b), opts)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(patch2)
+	require.NoError(t, err)
+
+	p2, err := jp.DecodePatch(data)
+	require.NoError(t, err)
+
+	applied, err := p2.Apply([]byte(a))
+	require.NoError(t, err)
+	require.JSONEq(t, b, string(applied))
+}
+
+func TestCreatePatchWithOptionsArrayKeysNestedPattern(t *testing.T) {
+	a := `{"spec":{"containers":[{"name":"app","ports":[{"containerPort":80},{"containerPort":443}]}]}}`
+	b := `{"spec":{"containers":[{"name":"app","ports":[{"containerPort":443},{"containerPort":8080}]}]}}`
+
+	opts := Options{
+		ArrayKeys: map[string][]string{
+			"/spec/containers":         {"name"},
+			"/spec/containers/*/ports": {"containerPort"},
+		},
+	}
+
+	patch, err := CreatePatchWithOptions(jsonOf(t, a), jsonOf(t, b), opts)
+	require.NoError(t, err)
+	require.Len(t, patch, 2)
+}
+
+func TestCreatePatchWithOptionsArrayKeysFallback(t *testing.T) {
+	// Items missing the identity field fall back to the default edit-distance diff.
+	a := `{"items":[{"name":"a"},{"other":"b"}]}`
+	b := `{"items":[{"name":"a2"},{"other":"b"}]}`
+
+	opts := Options{
+		ArrayKeys: map[string][]string{
+			"/items": {"name"},
+		},
+	}
+
+	patch, err := CreatePatchWithOptions(jsonOf(t, a), jsonOf(t, b), opts)
+	require.NoError(t, err)
+	require.Equal(t, []Operation{NewOperation("replace", "/items/0/name", "a2")}, patch)
+}
+
+func TestCreatePatchWithOptionsArrayKeysMultiInsertOrder(t *testing.T) {
+	// Two non-adjacent inserts must be emitted in ascending index order, or
+	// applying them at their recorded positions scrambles the result.
+	opts := Options{
+		ArrayKeys: map[string][]string{
+			"/items": {"id"},
+		},
+	}
+	a := `{"items":[{"id":"A"},{"id":"B"}]}`
+	b := `{"items":[{"id":"A"},{"id":"X"},{"id":"Y"},{"id":"B"}]}`
+
+	patch, err := CreatePatchWithOptions(jsonOf(t, a), jsonOf(t, b), opts)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(patch)
+	require.NoError(t, err)
+
+	p2, err := jp.DecodePatch(data)
+	require.NoError(t, err)
+
+	applied, err := p2.Apply([]byte(a))
+	require.NoError(t, err)
+	require.JSONEq(t, b, string(applied))
+}
+
+func TestCreatePatchWithOptionsArrayKeysMixedAddRemove(t *testing.T) {
+	// A remove and an add in the same keyed array (the Kubernetes
+	// tolerations scenario the ArrayKeys feature targets) must round-trip
+	// correctly, not just balance the op count.
+	opts := Options{
+		ArrayKeys: map[string][]string{
+			"/spec/tolerations": {"key"},
+		},
+	}
+	a := `{"spec":{"tolerations":[{"key":"k1"},{"key":"k2"},{"key":"k3"}]}}`
+	b := `{"spec":{"tolerations":[{"key":"k1"},{"key":"k3"},{"key":"k4"}]}}`
+
+	patch, err := CreatePatchWithOptions(jsonOf(t, a), jsonOf(t, b), opts)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(patch)
+	require.NoError(t, err)
+
+	p2, err := jp.DecodePatch(data)
+	require.NoError(t, err)
+
+	applied, err := p2.Apply([]byte(a))
+	require.NoError(t, err)
+	require.JSONEq(t, b, string(applied))
+}
+
+func jsonOf(t *testing.T, s string) any {
+	t.Helper()
+	var v any
+	require.NoError(t, json.Unmarshal([]byte(s), &v))
+	return v
+}
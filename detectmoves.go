@@ -0,0 +1,203 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// foldMoves rewrites a remove+add pair carrying the same JSON value into a
+// single "move" operation, and an add whose value also still exists
+// elsewhere in a into a "copy" operation, per RFC 6902 §4.3-4.5. It returns
+// patch unchanged unless opts.DetectMoves is set.
+func foldMoves(patch []Operation, a any, opts Options) []Operation {
+	if !opts.DetectMoves {
+		return patch
+	}
+
+	removedByHash := map[string][]string{}
+	for _, op := range patch {
+		if op.Operation != "remove" {
+			continue
+		}
+		if v, ok := valueAtPointer(a, op.Path); ok {
+			h := canonicalJSON(v)
+			removedByHash[h] = append(removedByHash[h], op.Path)
+		}
+	}
+
+	presentByHash := map[string][]string{}
+	walkPaths(a, "", func(path string, v any) {
+		presentByHash[canonicalJSON(v)] = append(presentByHash[canonicalJSON(v)], path)
+	})
+
+	consumedRemoves := map[string]bool{}
+	assignments := make(map[int]Operation, len(patch))
+	for i, op := range patch {
+		if op.Operation != "add" {
+			continue
+		}
+		h := canonicalJSON(op.Value)
+
+		if from, ok := shortestUnusedPath(removedByHash[h], consumedRemoves); ok {
+			if arrayFoldSafe(patch, from, op.Path) {
+				consumedRemoves[from] = true
+				assignments[i] = Operation{Operation: "move", Path: op.Path, From: from}
+				continue
+			}
+		}
+		if from, ok := shortestPath(presentByHash[h]); ok && from != op.Path {
+			if arrayFoldSafe(patch, from, op.Path) {
+				assignments[i] = Operation{Operation: "copy", Path: op.Path, From: from}
+			}
+		}
+	}
+
+	folded := make([]Operation, 0, len(patch))
+	for i, op := range patch {
+		if op.Operation == "remove" && consumedRemoves[op.Path] {
+			continue
+		}
+		// withTest always emits "test" directly before the op it guards; a
+		// consumed remove's test would otherwise survive pointing at a path
+		// the move replacing it has already vacated.
+		if op.Operation == "test" && i+1 < len(patch) {
+			next := patch[i+1]
+			if next.Operation == "remove" && next.Path == op.Path && consumedRemoves[op.Path] {
+				continue
+			}
+		}
+		if replacement, ok := assignments[i]; ok {
+			folded = append(folded, replacement)
+			continue
+		}
+		folded = append(folded, op)
+	}
+	return folded
+}
+
+// canonicalJSON renders v for value-equality hashing. encoding/json already
+// marshals map[string]any keys in sorted order, so equal values always
+// produce identical output.
+func canonicalJSON(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// valueAtPointer resolves an RFC 6901 JSON Pointer against doc.
+func valueAtPointer(doc any, pointer string) (any, bool) {
+	if pointer == "" {
+		return doc, true
+	}
+	cur := doc
+	for _, tok := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		tok = rfc6901Decoder.Replace(tok)
+		switch c := cur.(type) {
+		case map[string]any:
+			v, ok := c[tok]
+			if !ok {
+				return nil, false
+			}
+			cur = v
+		case []any:
+			idx, err := strconv.Atoi(tok)
+			if err != nil || idx < 0 || idx >= len(c) {
+				return nil, false
+			}
+			cur = c[idx]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// walkPaths visits every node of doc (including intermediate objects and
+// arrays, not just leaves) with its JSON Pointer path.
+func walkPaths(doc any, path string, visit func(path string, v any)) {
+	visit(path, doc)
+	switch t := doc.(type) {
+	case map[string]any:
+		for k, v := range t {
+			walkPaths(v, makePath(path, k), visit)
+		}
+	case []any:
+		for i, v := range t {
+			walkPaths(v, makePathInt(path, i), visit)
+		}
+	}
+}
+
+func shortestPath(paths []string) (string, bool) {
+	if len(paths) == 0 {
+		return "", false
+	}
+	best := paths[0]
+	for _, p := range paths[1:] {
+		if len(p) < len(best) {
+			best = p
+		}
+	}
+	return best, true
+}
+
+func shortestUnusedPath(paths []string, used map[string]bool) (string, bool) {
+	best := ""
+	found := false
+	for _, p := range paths {
+		if used[p] {
+			continue
+		}
+		if !found || len(p) < len(best) {
+			best = p
+			found = true
+		}
+	}
+	return best, found
+}
+
+// arrayFoldSafe reports whether folding the remove at fromPath and the add
+// at toPath into a single move/copy is safe, i.e. no other operation in
+// patch addresses an index of the same array. Folding changes when the
+// remove takes effect relative to such operations, which would otherwise
+// invalidate their indices.
+func arrayFoldSafe(patch []Operation, fromPath, toPath string) bool {
+	if !isArrayIndexPath(fromPath) && !isArrayIndexPath(toPath) {
+		return true
+	}
+	fromParent := pathParent(fromPath)
+	toParent := pathParent(toPath)
+	for _, op := range patch {
+		if op.Path == fromPath || op.Path == toPath {
+			continue
+		}
+		if withinArray(op.Path, fromParent) || withinArray(op.Path, toParent) {
+			return false
+		}
+	}
+	return true
+}
+
+func isArrayIndexPath(path string) bool {
+	i := strings.LastIndex(path, "/")
+	if i < 0 {
+		return false
+	}
+	_, err := strconv.Atoi(path[i+1:])
+	return err == nil
+}
+
+func pathParent(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return ""
+	}
+	return path[:i]
+}
+
+func withinArray(path, arrayPath string) bool {
+	return arrayPath != "" && strings.HasPrefix(path, arrayPath+"/")
+}
@@ -0,0 +1,98 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"testing"
+
+	jp "github.com/evanphx/json-patch"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreatePatchWithOptionsDetectMoves(t *testing.T) {
+	a := `{"a":{"x":1},"c":"unchanged"}`
+	b := `{"b":{"x":1},"c":"unchanged"}`
+
+	patch, err := CreatePatchWithOptions(jsonOf(t, a), jsonOf(t, b), Options{DetectMoves: true})
+	require.NoError(t, err)
+	require.Equal(t, []Operation{{Operation: "move", Path: "/b", From: "/a"}}, patch)
+
+	applyAndCheck(t, patch, a, b)
+}
+
+func TestCreatePatchWithOptionsDetectMovesDisabledByDefault(t *testing.T) {
+	a := `{"a":{"x":1},"c":"unchanged"}`
+	b := `{"b":{"x":1},"c":"unchanged"}`
+
+	patch, err := CreatePatchWithOptions(jsonOf(t, a), jsonOf(t, b), Options{})
+	require.NoError(t, err)
+	require.Len(t, patch, 2, "without DetectMoves the add/remove pair is left as-is")
+
+	applyAndCheck(t, patch, a, b)
+}
+
+func TestCreatePatchWithOptionsDetectCopy(t *testing.T) {
+	a := `{"a":{"x":1}}`
+	b := `{"a":{"x":1},"b":{"x":1}}`
+
+	patch, err := CreatePatchWithOptions(jsonOf(t, a), jsonOf(t, b), Options{DetectMoves: true})
+	require.NoError(t, err)
+	require.Equal(t, []Operation{{Operation: "copy", Path: "/b", From: "/a"}}, patch)
+
+	applyAndCheck(t, patch, a, b)
+}
+
+func TestCreatePatchWithOptionsDetectMovesSkipsUnsafeArrayReorder(t *testing.T) {
+	// "/extra" gets the value still present at "/items/0", a copy candidate,
+	// but other operations ("/items/1", "/items/0/id") address the same
+	// array, so folding the copy would read "/items/0" after they ran.
+	a := `{"items":[{"id":1},{"id":2}]}`
+	b := `{"items":[{"id":3}],"extra":{"id":1}}`
+
+	patch, err := CreatePatchWithOptions(jsonOf(t, a), jsonOf(t, b), Options{DetectMoves: true})
+	require.NoError(t, err)
+
+	for _, op := range patch {
+		require.NotContains(t, []string{"move", "copy"}, op.Operation, "fold must be skipped when it would invalidate a sibling array op's index")
+	}
+
+	applyAndCheck(t, patch, a, b)
+}
+
+func TestOperationMarshalMoveAndCopy(t *testing.T) {
+	move := Operation{Operation: "move", Path: "/b", From: "/a"}
+	data, err := json.Marshal(&move)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"op":"move","path":"/b","from":"/a"}`, string(data))
+
+	cp := Operation{Operation: "copy", Path: "/b", From: "/a"}
+	data, err = json.Marshal(&cp)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"op":"copy","path":"/b","from":"/a"}`, string(data))
+}
+
+func TestCreatePatchWithOptionsDetectMovesWithEmitTests(t *testing.T) {
+	// The test op guarding the folded remove must not survive the fold: it
+	// would assert the old path's value after the move has already vacated it.
+	a := `{"a":{"x":1},"c":"unchanged"}`
+	b := `{"b":{"x":1},"c":"unchanged"}`
+
+	patch, err := CreatePatchWithOptions(jsonOf(t, a), jsonOf(t, b), Options{EmitTests: true, DetectMoves: true})
+	require.NoError(t, err)
+	require.Equal(t, []Operation{{Operation: "move", Path: "/b", From: "/a"}}, patch)
+
+	applyAndCheck(t, patch, a, b)
+}
+
+func applyAndCheck(t *testing.T, patch []Operation, src, dst string) {
+	t.Helper()
+
+	data, err := json.Marshal(patch)
+	require.NoError(t, err)
+
+	p, err := jp.DecodePatch(data)
+	require.NoError(t, err)
+
+	out, err := p.Apply([]byte(src))
+	require.NoError(t, err)
+	require.JSONEq(t, dst, string(out))
+}
@@ -0,0 +1,58 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"testing"
+
+	jp "github.com/evanphx/json-patch"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreatePatchWithOptionsEmitTests(t *testing.T) {
+	opts := Options{EmitTests: true}
+
+	patch, err := CreatePatchWithOptions(jsonOf(t, simpleA), jsonOf(t, simpleB), opts)
+	require.NoError(t, err)
+	require.Equal(t, []Operation{
+		NewOperation("test", "/c", "hello"),
+		NewOperation("replace", "/c", "goodbye"),
+	}, patch)
+}
+
+func TestCreatePatchWithOptionsEmitTestsRemove(t *testing.T) {
+	opts := Options{EmitTests: true}
+
+	patch, err := CreatePatchWithOptions(jsonOf(t, simpleA), jsonOf(t, simpleE), opts)
+	require.NoError(t, err)
+	require.Equal(t, []Operation{
+		NewOperation("test", "/c", "hello"),
+		NewOperation("remove", "/c", nil),
+	}, patch)
+}
+
+// TestCreatePatchWithOptionsEmitTestsConcurrentModification verifies that a
+// patch with EmitTests fails to apply once the source document has been
+// concurrently modified since the diff was computed.
+func TestCreatePatchWithOptionsEmitTestsConcurrentModification(t *testing.T) {
+	opts := Options{EmitTests: true}
+
+	patch, err := CreatePatchWithOptions(jsonOf(t, simpleA), jsonOf(t, simpleB), opts)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(patch)
+	require.NoError(t, err)
+
+	p, err := jp.DecodePatch(data)
+	require.NoError(t, err)
+
+	// Applying to the untouched source document succeeds.
+	out, err := p.Apply([]byte(simpleA))
+	require.NoError(t, err)
+	require.JSONEq(t, simpleB, string(out))
+
+	// A concurrent writer changes "c" before the patch is applied: the test
+	// operation should now fail instead of silently clobbering the change.
+	tampered := `{"a":100, "b":200, "c":"tampered"}`
+	_, err = p.Apply([]byte(tampered))
+	require.Error(t, err)
+}
@@ -14,7 +14,10 @@ var errBadJSONDoc = fmt.Errorf("invalid JSON Document")
 type Operation struct {
 	Operation string `json:"op"`
 	Path      string `json:"path"`
-	Value     any    `json:"value,omitempty"`
+	// From is the source path for "move" and "copy" operations (RFC 6902
+	// §4.3-4.4). Unused by every other operation.
+	From  string `json:"from,omitempty"`
+	Value any    `json:"value,omitempty"`
 }
 
 func (j *Operation) MarshalJSON() ([]byte, error) {
@@ -31,8 +34,14 @@ func (j *Operation) MarshalJSON() ([]byte, error) {
 	b.WriteString(j.Path)
 	b.WriteByte('"')
 
+	if j.From != "" {
+		b.WriteString(`,"from":"`)
+		b.WriteString(j.From)
+		b.WriteByte('"')
+	}
+
 	// Consider omitting Value for non-nullable operations.
-	if j.Value != nil || j.Operation == "replace" || j.Operation == "add" {
+	if j.Value != nil || j.Operation == "replace" || j.Operation == "add" || j.Operation == "test" {
 		v, err := json.Marshal(j.Value)
 		if err != nil {
 			return nil, err
@@ -54,9 +63,36 @@ func NewOperation(op, path string, value any) Operation {
 	return Operation{Operation: op, Path: path, Value: value}
 }
 
+// withTest appends op to patch, preceded by a "test" operation asserting
+// original (the value at path in the source document) when opts.EmitTests
+// is set. Used for "replace" and "remove", the two mutations that destroy
+// data a concurrent writer may have changed.
+func withTest(patch []Operation, op, path string, value, original any, opts Options) []Operation {
+	if opts.EmitTests {
+		patch = append(patch, NewOperation("test", path, original))
+	}
+	return append(patch, NewOperation(op, path, value))
+}
+
 // CreatePatch accepts already prepared objects. Look at CreatePatchFromBytes
 func CreatePatch(a, b any) ([]Operation, error) {
-	return handleValues(a, b, "", []Operation{})
+	return CreatePatchWithOptions(a, b, Options{})
+}
+
+// CreatePatchWithOptions is like CreatePatch but allows customizing the diff
+// via Options, e.g. matching arrays of objects by an identity field instead
+// of the default positional edit-distance diff.
+func CreatePatchWithOptions(a, b any, opts Options) ([]Operation, error) {
+	opts, err := compileIgnorePaths(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	patch, err := handleValues(a, b, "", []Operation{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	return foldMoves(patch, a, opts), nil
 }
 
 // CreatePatchFromBytes creates a patch as specified in http://jsonpatch.com/
@@ -145,10 +181,9 @@ func matchesValue(av, bv any) bool {
 // character sequence.  This is performed by first transforming any
 // occurrence of the sequence '~1' to '/', and then transforming any
 // occurrence of the sequence '~0' to '~'.
-//   TODO decode support:
-//   var rfc6901Decoder = strings.NewReplacer("~1", "/", "~0", "~")
 
 var rfc6901Encoder = strings.NewReplacer("~", "~0", "/", "~1")
+var rfc6901Decoder = strings.NewReplacer("~1", "/", "~0", "~")
 
 var bufferPool = &sync.Pool{
 	New: func() any {
@@ -188,9 +223,12 @@ func makePath(path string, newPart string) string {
 }
 
 // diff returns the (recursive) difference between a and b as an array of JsonPatchOperations.
-func diff(a, b map[string]any, path string, patch []Operation) ([]Operation, error) {
+func diff(a, b map[string]any, path string, patch []Operation, opts Options) ([]Operation, error) {
 	for key, bv := range b {
 		p := makePath(path, key)
+		if pathIgnored(opts, p) {
+			continue
+		}
 		av, ok := a[key]
 		// value was added
 		if !ok {
@@ -199,7 +237,7 @@ func diff(a, b map[string]any, path string, patch []Operation) ([]Operation, err
 		}
 		// Types are the same, compare values
 		var err error
-		patch, err = handleValues(av, bv, p, patch)
+		patch, err = handleValues(av, bv, p, patch, opts)
 		if err != nil {
 			return nil, err
 		}
@@ -209,8 +247,11 @@ func diff(a, b map[string]any, path string, patch []Operation) ([]Operation, err
 		_, found := b[key]
 		if !found {
 			p := makePath(path, key)
+			if pathIgnored(opts, p) {
+				continue
+			}
 
-			patch = append(patch, NewOperation("remove", p, nil))
+			patch = withTest(patch, "remove", p, nil, a[key], opts)
 		}
 	}
 	return patch, nil
@@ -235,14 +276,14 @@ func typesAreCompatible(av, bv any) bool {
 	return false
 }
 
-func handleValues(av, bv any, p string, patch []Operation) ([]Operation, error) {
+func handleValues(av, bv any, p string, patch []Operation, opts Options) ([]Operation, error) {
 	{
 		if av == nil && bv == nil {
 			return patch, nil
 		}
 		if !typesAreCompatible(av, bv) {
 			// If types have changed, replace completely (preserves null in destination)
-			return append(patch, NewOperation("replace", p, bv)), nil
+			return withTest(patch, "replace", p, bv, av, opts), nil
 		}
 	}
 
@@ -250,29 +291,50 @@ func handleValues(av, bv any, p string, patch []Operation) ([]Operation, error)
 	switch at := av.(type) {
 	case map[string]any:
 		bt := bv.(map[string]any)
-		patch, err = diff(at, bt, p, patch)
+		patch, err = diff(at, bt, p, patch, opts)
 		if err != nil {
 			return nil, err
 		}
 	case string, float64, bool:
 		if !matchesValue(av, bv) {
-			patch = append(patch, NewOperation("replace", p, bv))
+			patch = withTest(patch, "replace", p, bv, av, opts)
 		}
 	case []any:
 		bt := bv.([]any)
+		if fields, ok := arrayKeyFields(opts, p); ok {
+			keyed, handled, err := diffKeyedArray(at, bt, fields, p, patch, opts)
+			if err != nil {
+				return nil, err
+			}
+			if handled {
+				return keyed, nil
+			}
+		}
 		if isSimpleArray(at) && isSimpleArray(bt) {
-			patch = append(patch, compareEditDistance(at, bt, p)...)
+			patch = append(patch, compareEditDistance(at, bt, p, opts)...)
 		} else {
 			n := min(len(at), len(bt))
 			for i := len(at) - 1; i >= n; i-- {
-				patch = append(patch, NewOperation("remove", makePathInt(p, i), nil))
+				ip := makePathInt(p, i)
+				if pathIgnored(opts, ip) {
+					continue
+				}
+				patch = withTest(patch, "remove", ip, nil, at[i], opts)
 			}
 			for i := n; i < len(bt); i++ {
-				patch = append(patch, NewOperation("add", makePathInt(p, i), bt[i]))
+				ip := makePathInt(p, i)
+				if pathIgnored(opts, ip) {
+					continue
+				}
+				patch = append(patch, NewOperation("add", ip, bt[i]))
 			}
 			for i := 0; i < n; i++ {
+				ip := makePathInt(p, i)
+				if pathIgnored(opts, ip) {
+					continue
+				}
 				var err error
-				patch, err = handleValues(at[i], bt[i], makePathInt(p, i), patch)
+				patch, err = handleValues(at[i], bt[i], ip, patch, opts)
 				if err != nil {
 					return nil, err
 				}
@@ -320,7 +382,7 @@ func isSimpleArray(a []any) bool {
 
 // https://en.wikipedia.org/wiki/Wagner%E2%80%93Fischer_algorithm
 // Adapted from https://github.com/texttheater/golang-levenshtein
-func compareEditDistance(s, t []any, p string) []Operation {
+func compareEditDistance(s, t []any, p string, opts Options) []Operation {
 	m := len(s)
 	n := len(t)
 
@@ -346,7 +408,7 @@ func compareEditDistance(s, t []any, p string) []Operation {
 		}
 	}
 
-	return backtrace(s, t, p, m, n, d)
+	return backtrace(s, t, p, m, n, d, opts)
 }
 
 func min(x int, y int) int {
@@ -356,26 +418,26 @@ func min(x int, y int) int {
 	return x
 }
 
-func backtrace(s, t []any, p string, i int, j int, matrix [][]int) []Operation {
+func backtrace(s, t []any, p string, i int, j int, matrix [][]int, opts Options) []Operation {
 	if i > 0 && matrix[i-1][j]+1 == matrix[i][j] {
-		op := NewOperation("remove", makePathInt(p, i-1), nil)
-		return append([]Operation{op}, backtrace(s, t, p, i-1, j, matrix)...)
+		ops := withTest(nil, "remove", makePathInt(p, i-1), nil, s[i-1], opts)
+		return append(ops, backtrace(s, t, p, i-1, j, matrix, opts)...)
 	}
 	if j > 0 && matrix[i][j-1]+1 == matrix[i][j] {
 		op := NewOperation("add", makePathInt(p, i), t[j-1])
-		return append([]Operation{op}, backtrace(s, t, p, i, j-1, matrix)...)
+		return append([]Operation{op}, backtrace(s, t, p, i, j-1, matrix, opts)...)
 	}
 	if i > 0 && j > 0 && matrix[i-1][j-1]+1 == matrix[i][j] {
 		if isBasicType(s[0]) {
-			op := NewOperation("replace", makePathInt(p, i-1), t[j-1])
-			return append([]Operation{op}, backtrace(s, t, p, i-1, j-1, matrix)...)
+			ops := withTest(nil, "replace", makePathInt(p, i-1), t[j-1], s[i-1], opts)
+			return append(ops, backtrace(s, t, p, i-1, j-1, matrix, opts)...)
 		}
 
-		p2, _ := handleValues(s[i-1], t[j-1], makePathInt(p, i-1), []Operation{})
-		return append(p2, backtrace(s, t, p, i-1, j-1, matrix)...)
+		p2, _ := handleValues(s[i-1], t[j-1], makePathInt(p, i-1), []Operation{}, opts)
+		return append(p2, backtrace(s, t, p, i-1, j-1, matrix, opts)...)
 	}
 	if i > 0 && j > 0 && matrix[i-1][j-1] == matrix[i][j] {
-		return backtrace(s, t, p, i-1, j-1, matrix)
+		return backtrace(s, t, p, i-1, j-1, matrix, opts)
 	}
 	return []Operation{}
 }
@@ -0,0 +1,168 @@
+package jsonpatch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type segKind int
+
+const (
+	segName segKind = iota
+	segWildcard
+	segIndex
+	segIndexWildcard
+	segRecursive
+)
+
+type pathSegment struct {
+	kind segKind
+	name string
+	idx  int
+}
+
+// compileIgnorePaths compiles every expression in opts.IgnorePaths and
+// stores the result in opts.compiledIgnorePaths.
+func compileIgnorePaths(opts Options) (Options, error) {
+	if len(opts.IgnorePaths) == 0 {
+		return opts, nil
+	}
+	compiled := make([][]pathSegment, len(opts.IgnorePaths))
+	for i, expr := range opts.IgnorePaths {
+		segs, err := compileJSONPath(expr)
+		if err != nil {
+			return opts, err
+		}
+		compiled[i] = segs
+	}
+	opts.compiledIgnorePaths = compiled
+	return opts, nil
+}
+
+// compileJSONPath parses a subset of JSONPath: "$.name", "$.*", "$[*]",
+// "$[N]" and "$..name" (recursive descent), in any combination.
+func compileJSONPath(expr string) ([]pathSegment, error) {
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("jsonpatch: JSONPath must start with '$': %q", expr)
+	}
+
+	var segs []pathSegment
+	rest := expr[1:]
+	for len(rest) > 0 {
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			rest = rest[2:]
+			name, tail, err := scanName(expr, rest)
+			if err != nil {
+				return nil, err
+			}
+			segs = append(segs, pathSegment{kind: segRecursive}, pathSegment{kind: segName, name: name})
+			rest = tail
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+			if strings.HasPrefix(rest, "*") {
+				segs = append(segs, pathSegment{kind: segWildcard})
+				rest = rest[1:]
+				continue
+			}
+			name, tail, err := scanName(expr, rest)
+			if err != nil {
+				return nil, err
+			}
+			segs = append(segs, pathSegment{kind: segName, name: name})
+			rest = tail
+		case strings.HasPrefix(rest, "["):
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("jsonpatch: unterminated '[' in JSONPath: %q", expr)
+			}
+			inner := rest[1:end]
+			if inner == "*" {
+				segs = append(segs, pathSegment{kind: segIndexWildcard})
+			} else {
+				idx, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("jsonpatch: invalid index %q in JSONPath: %q", inner, expr)
+				}
+				segs = append(segs, pathSegment{kind: segIndex, idx: idx})
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("jsonpatch: invalid JSONPath: %q", expr)
+		}
+	}
+	return segs, nil
+}
+
+func scanName(expr, s string) (name, rest string, err error) {
+	i := 0
+	for i < len(s) && s[i] != '.' && s[i] != '[' {
+		i++
+	}
+	if i == 0 {
+		return "", "", fmt.Errorf("jsonpatch: expected a name in JSONPath: %q", expr)
+	}
+	return s[:i], s[i:], nil
+}
+
+// matchExact reports whether pat fully consumes tok, walking the NFA formed
+// by pat's segments (segRecursive branches over every possible split).
+func matchExact(pat []pathSegment, tok []string) bool {
+	if len(pat) == 0 {
+		return len(tok) == 0
+	}
+	switch pat[0].kind {
+	case segName:
+		if len(tok) == 0 || tok[0] != pat[0].name {
+			return false
+		}
+		return matchExact(pat[1:], tok[1:])
+	case segWildcard, segIndexWildcard:
+		if len(tok) == 0 {
+			return false
+		}
+		return matchExact(pat[1:], tok[1:])
+	case segIndex:
+		if len(tok) == 0 || tok[0] != strconv.Itoa(pat[0].idx) {
+			return false
+		}
+		return matchExact(pat[1:], tok[1:])
+	case segRecursive:
+		for i := 0; i <= len(tok); i++ {
+			if matchExact(pat[1:], tok[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// pathIgnored reports whether p (or an ancestor of p) matches one of
+// opts.compiledIgnorePaths, i.e. p falls inside an ignored subtree.
+func pathIgnored(opts Options, p string) bool {
+	if len(opts.compiledIgnorePaths) == 0 {
+		return false
+	}
+	tokens := pointerTokens(p)
+	for _, pat := range opts.compiledIgnorePaths {
+		for k := 0; k <= len(tokens); k++ {
+			if matchExact(pat, tokens[:k]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func pointerTokens(p string) []string {
+	if p == "" {
+		return nil
+	}
+	toks := strings.Split(strings.TrimPrefix(p, "/"), "/")
+	for i, t := range toks {
+		toks[i] = rfc6901Decoder.Replace(t)
+	}
+	return toks
+}
@@ -0,0 +1,140 @@
+package jsonpatch
+
+import "testing"
+
+func TestCompileJSONPathAndMatch(t *testing.T) {
+	cases := []struct {
+		name    string
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"FieldMatch", "$.a.b", "/a/b", true},
+		{"FieldMismatch", "$.a.b", "/a/c", false},
+		{"FieldMatchIgnoresSubtree", "$.a.b", "/a/b/c", true},
+		{"Wildcard", "$.a.*", "/a/anything", true},
+		{"WildcardSubtree", "$.a.*", "/a/anything/nested", true},
+		{"WildcardDoesNotSkipSegment", "$.a.*", "/a", false},
+		{"IndexWildcard", "$.a[*].b", "/a/0/b", true},
+		{"IndexWildcardMismatchField", "$.a[*].b", "/a/0/c", false},
+		{"RecursiveDescent", "$..x", "/a/b/x", true},
+		{"RecursiveDescentAtRoot", "$..x", "/x", true},
+		{"RecursiveDescentNoMatch", "$..x", "/a/b/y", false},
+		{"ExactIndex", "$.a[1]", "/a/1", true},
+		{"ExactIndexMismatch", "$.a[1]", "/a/0", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			segs, err := compileJSONPath(c.pattern)
+			if err != nil {
+				t.Fatalf("compileJSONPath(%q): %v", c.pattern, err)
+			}
+			opts := Options{compiledIgnorePaths: [][]pathSegment{segs}}
+			if got := pathIgnored(opts, c.path); got != c.want {
+				t.Errorf("pathIgnored(%q against %q) = %v, want %v", c.path, c.pattern, got, c.want)
+			}
+		})
+	}
+}
+
+func TestCompileJSONPathInvalid(t *testing.T) {
+	cases := []string{"a.b", "$.", "$[", "$[x]"}
+	for _, pattern := range cases {
+		if _, err := compileJSONPath(pattern); err == nil {
+			t.Errorf("compileJSONPath(%q): expected error, got nil", pattern)
+		}
+	}
+}
+
+func TestCreatePatchWithOptionsIgnorePathsInvalidPattern(t *testing.T) {
+	_, err := CreatePatchWithOptions(jsonOf(t, `{}`), jsonOf(t, `{}`), Options{IgnorePaths: []string{"not-a-path"}})
+	if err == nil {
+		t.Fatal("expected error for invalid JSONPath")
+	}
+}
+
+var (
+	deploymentBase = `{
+  "apiVersion": "apps/v1",
+  "kind": "Deployment",
+  "metadata": {
+    "name": "web",
+    "namespace": "demo",
+    "resourceVersion": "111",
+    "managedFields": [{"manager": "kubectl", "time": "2020-01-01T00:00:00Z"}]
+  },
+  "spec": {
+    "replicas": 3
+  },
+  "status": {
+    "replicas": 3,
+    "availableReplicas": 3
+  }
+}`
+
+	deploymentOnlyServerFieldsChanged = `{
+  "apiVersion": "apps/v1",
+  "kind": "Deployment",
+  "metadata": {
+    "name": "web",
+    "namespace": "demo",
+    "resourceVersion": "112",
+    "managedFields": [{"manager": "kube-controller-manager", "time": "2020-01-02T00:00:00Z"}]
+  },
+  "spec": {
+    "replicas": 3
+  },
+  "status": {
+    "replicas": 2,
+    "availableReplicas": 2,
+    "conditions": [{"type": "Available", "status": "True"}]
+  }
+}`
+
+	deploymentSpecChanged = `{
+  "apiVersion": "apps/v1",
+  "kind": "Deployment",
+  "metadata": {
+    "name": "web",
+    "namespace": "demo",
+    "resourceVersion": "112",
+    "managedFields": [{"manager": "kube-controller-manager", "time": "2020-01-02T00:00:00Z"}]
+  },
+  "spec": {
+    "replicas": 5
+  },
+  "status": {
+    "replicas": 2,
+    "availableReplicas": 2
+  }
+}`
+)
+
+func TestCreatePatchWithOptionsIgnorePathsKubernetesDeployment(t *testing.T) {
+	opts := Options{
+		IgnorePaths: []string{"$.metadata.managedFields", "$.metadata.resourceVersion", "$.status"},
+	}
+
+	patch, err := CreatePatchWithOptions(jsonOf(t, deploymentBase), jsonOf(t, deploymentOnlyServerFieldsChanged), opts)
+	if err != nil {
+		t.Fatalf("CreatePatchWithOptions: %v", err)
+	}
+	if len(patch) != 0 {
+		t.Fatalf("expected an empty patch when only ignored fields differ, got %+v", patch)
+	}
+}
+
+func TestCreatePatchWithOptionsIgnorePathsStillSeesRealChanges(t *testing.T) {
+	opts := Options{
+		IgnorePaths: []string{"$.metadata.managedFields", "$.status", "$.metadata.resourceVersion"},
+	}
+
+	patch, err := CreatePatchWithOptions(jsonOf(t, deploymentBase), jsonOf(t, deploymentSpecChanged), opts)
+	if err != nil {
+		t.Fatalf("CreatePatchWithOptions: %v", err)
+	}
+	if len(patch) != 1 || patch[0].Path != "/spec/replicas" {
+		t.Fatalf("expected a single /spec/replicas change, got %+v", patch)
+	}
+}
@@ -0,0 +1,68 @@
+package jsonpatch
+
+import "encoding/json"
+
+// CreateMergePatch accepts already prepared objects. Look at CreateMergePatchFromBytes
+func CreateMergePatch(a, b any) ([]byte, error) {
+	return json.Marshal(mergePatchValue(a, b))
+}
+
+// CreateMergePatchFromBytes creates a merge patch as specified in RFC 7396
+// (https://tools.ietf.org/html/rfc7396).
+//
+// 'a' is original, 'b' is the modified document. Both are to be given as json encoded content.
+// The function will return the merge patch document, which, unlike CreatePatch, is itself a
+// JSON value rather than an array of operations.
+//
+// An error will be returned if any of the two documents are invalid.
+func CreateMergePatchFromBytes(a, b []byte) ([]byte, error) {
+	var aI any
+	var bI any
+	err := json.Unmarshal(a, &aI)
+	if err != nil {
+		return nil, errBadJSONDoc
+	}
+	err = json.Unmarshal(b, &bI)
+	if err != nil {
+		return nil, errBadJSONDoc
+	}
+
+	return CreateMergePatch(aI, bI)
+}
+
+// mergePatchValue computes the RFC 7396 merge patch that turns av into bv.
+//
+// If both sides are objects, the result is an object containing the merge patch of every
+// differing key plus an explicit null for every key removed in b. Otherwise (including when
+// either side is an array, since RFC 7396 has no way to express indexed array edits) the
+// result is bv verbatim.
+func mergePatchValue(av, bv any) any {
+	at, aIsObj := av.(map[string]any)
+	bt, bIsObj := bv.(map[string]any)
+	if !aIsObj || !bIsObj {
+		return bv
+	}
+
+	merged := map[string]any{}
+	for key, bChild := range bt {
+		aChild, ok := at[key]
+		if !ok {
+			// Key did not exist in a: emit bChild verbatim, even if it is an explicit null.
+			merged[key] = bChild
+			continue
+		}
+		if aChild == nil && bChild == nil {
+			continue
+		}
+		if matchesValue(aChild, bChild) {
+			continue
+		}
+		merged[key] = mergePatchValue(aChild, bChild)
+	}
+	for key := range at {
+		if _, ok := bt[key]; !ok {
+			merged[key] = nil
+		}
+	}
+	return merged
+}
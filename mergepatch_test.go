@@ -0,0 +1,99 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateMergePatch(t *testing.T) {
+	cases := []struct {
+		name     string
+		a        string
+		b        string
+		expected string
+	}{
+		// examples from https://tools.ietf.org/html/rfc7396#appendix-A
+		{"ReplaceKey", `{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"AddKey", `{"a":"b"}`, `{"b":"c"}`, `{"a":null,"b":"c"}`},
+		{"RemoveKeyNull", `{"a":"b"}`, `{"a":null}`, `{"a":null}`},
+		{"ReplaceStringWithArray", `{"a":"b"}`, `{"a":["c"]}`, `{"a":["c"]}`},
+		{"ReplaceValueWithArray", `["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{"ReplaceObjectWithArray", `{"a":"b"}`, `["c"]`, `["c"]`},
+		{"ReplaceObjectWithNull", `{"a":"foo"}`, `null`, `null`},
+		{"ReplaceObjectWithString", `{"a":"foo"}`, `"bar"`, `"bar"`},
+		{"AddKeyAndRemoveNullKey", `{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{"ArrayReplacedWholesale", `{"a":[1,2]}`, `{"a":[1,2,3]}`, `{"a":[1,2,3]}`},
+		{
+			"NestedObjectMerge",
+			`{"a":{"b":"c"},"d":"e"}`,
+			`{"a":{"b":"f"},"d":"e"}`,
+			`{"a":{"b":"f"}}`,
+		},
+		{
+			"NestedObjectKeyRemoved",
+			`{"a":{"b":"c"},"d":"e"}`,
+			`{"a":{"b":"c"}}`,
+			`{"d":null}`,
+		},
+		// explicit null on the destination side for a key that did not exist in the
+		// source must be emitted literally, not swallowed as a no-op deletion.
+		{"ExplicitNullOnNewKey", `{}`, `{"a":null}`, `{"a":null}`},
+		{"NoChange", `{"a":"b"}`, `{"a":"b"}`, `{}`},
+		{"UnchangedNullKey", `{"a":null,"b":"c"}`, `{"a":null,"b":"c"}`, `{}`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			patch, err := CreateMergePatchFromBytes([]byte(c.a), []byte(c.b))
+			require.NoError(t, err)
+			require.JSONEq(t, c.expected, string(patch))
+		})
+	}
+}
+
+func TestCreateMergePatchRoundTrip(t *testing.T) {
+	a := `{"title":"Goodbye!","author":{"givenName":"John","familyName":"Doe"},"tags":["example","sample"],"content":"This will be unchanged"}`
+	b := `{"title":"Hello!","author":{"givenName":"John"},"tags":["example"],"content":"This will be unchanged","phoneNumber":"+01-123-456-7890"}`
+
+	patch, err := CreateMergePatchFromBytes([]byte(a), []byte(b))
+	require.NoError(t, err)
+
+	var aI, patchI any
+	require.NoError(t, json.Unmarshal([]byte(a), &aI))
+	require.NoError(t, json.Unmarshal(patch, &patchI))
+
+	merged := applyMergePatch(aI, patchI)
+
+	got, err := json.Marshal(merged)
+	require.NoError(t, err)
+	require.JSONEq(t, b, string(got))
+}
+
+// applyMergePatch is a minimal RFC 7396 applier used only to verify
+// CreateMergePatch round-trips; the package does not otherwise apply patches.
+func applyMergePatch(target, patch any) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]any)
+	if !ok {
+		targetObj = map[string]any{}
+	}
+
+	result := map[string]any{}
+	for k, v := range targetObj {
+		result[k] = v
+	}
+	for k, v := range patchObj {
+		if v == nil {
+			delete(result, k)
+			continue
+		}
+		result[k] = applyMergePatch(result[k], v)
+	}
+	return result
+}
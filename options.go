@@ -0,0 +1,45 @@
+package jsonpatch
+
+// Options configures optional behaviors for CreatePatchWithOptions.
+type Options struct {
+	// ArrayKeys maps a JSON Pointer path (an exact pointer, or one using "*"
+	// as a single-segment wildcard, e.g. "/spec/containers/*/ports") to the
+	// field name(s) that uniquely identify elements of the array at that
+	// path. When set, arrays of objects under a matching path are diffed by
+	// matching elements on these identity fields instead of positional
+	// edit-distance, mirroring Kubernetes' strategic-merge-patch
+	// patchMergeKey. Elements missing an identity field fall back to the
+	// default edit-distance diff for that array.
+	ArrayKeys map[string][]string
+
+	// EmitTests causes CreatePatchWithOptions to prepend a "test" operation,
+	// asserting the original value from a, before every "replace" and
+	// "remove" operation it emits. This guards against applying the patch to
+	// a document that has been concurrently modified since the diff was
+	// computed (e.g. admission webhooks, ETag-guarded PATCH requests): the
+	// test fails and the whole patch is rejected instead of silently
+	// clobbering the concurrent change.
+	EmitTests bool
+
+	// DetectMoves enables a post-processing pass that folds a matching
+	// remove+add pair (same JSON value) into a single "move" operation, and
+	// an add whose value also still exists elsewhere in a into a "copy"
+	// operation. It is opt-in because moves change apply semantics when
+	// another operation in the patch references a path invalidated by the
+	// reordering; the pass skips folding in that case.
+	DetectMoves bool
+
+	// IgnorePaths excludes fields from the diff using a subset of JSONPath:
+	// "$.a.b" (field), "$.a.*" (wildcard field), "$.a[*].b" (wildcard array
+	// element) and "$..x" (recursive descent). A path that matches is
+	// skipped along with everything nested under it, e.g. "$.status" drops
+	// the whole status subtree. Useful for server-populated Kubernetes
+	// fields such as metadata.resourceVersion, metadata.managedFields and
+	// status.*.
+	IgnorePaths []string
+
+	// compiledIgnorePaths caches the compiled form of IgnorePaths for the
+	// lifetime of a single CreatePatchWithOptions call; populated by
+	// compileIgnorePaths.
+	compiledIgnorePaths [][]pathSegment
+}